@@ -0,0 +1,25 @@
+package lib
+
+import "io"
+
+// Backend generates output for a parsed Module in some target
+// language/format, selectable via the CLI's `gen -lang` flag.
+type Backend interface {
+	Name() string
+	Generate(mod Module, w io.Writer) error
+}
+
+var backends = map[string]Backend{}
+
+// RegisterBackend adds b to the backend registry under b.Name(), so it can
+// be selected via `codeGen gen -lang <name>`. Out-of-tree plugins that
+// import this package can call RegisterBackend from their own init().
+func RegisterBackend(b Backend) {
+	backends[b.Name()] = b
+}
+
+// GetBackend looks up a previously registered backend by name.
+func GetBackend(name string) (Backend, bool) {
+	b, ok := backends[name]
+	return b, ok
+}
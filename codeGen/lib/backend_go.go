@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// goBackend emits Go source for a Module: a type per struct/union and a
+// stub method per method prototype.
+type goBackend struct{}
+
+func (goBackend) Name() string { return "go" }
+
+// fieldGoType maps a DSL field/arg type to a Go type. Unknown DSL types
+// (struct/union names) pass through unchanged.
+func fieldGoType(t string) string {
+	switch t {
+	case "Bytes":
+		return "[]byte"
+	case "UInt":
+		return "uint64"
+	case "Int":
+		return "int64"
+	case "Bool":
+		return "bool"
+	case "String":
+		return "string"
+	default:
+		return t
+	}
+}
+
+func (goBackend) Generate(mod Module, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "// Code generated by codeGen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(bw, "package %s\n", mod.PackageName)
+
+	for _, d := range mod.Decls() {
+		fmt.Fprintln(bw)
+		switch d.Kind() {
+		case DeclStruct:
+			fmt.Fprintf(bw, "type %s struct {\n", d.Name())
+			for _, f := range d.Fields() {
+				fmt.Fprintf(bw, "\t%s %s\n", f.Name, fieldGoType(f.Type))
+			}
+			fmt.Fprintf(bw, "}\n")
+		case DeclUnion:
+			fmt.Fprintf(bw, "type %s interface {\n\tis%s()\n}\n", d.Name(), d.Name())
+			for _, f := range d.Fields() {
+				// Each case gets its own named type, even when its underlying
+				// DSL type is a builtin or shared with another case: Go can't
+				// define methods on a bare builtin, and two cases of the same
+				// underlying type would otherwise collide on the same method.
+				caseType := d.Name() + "_" + f.Name
+				fmt.Fprintf(bw, "\ntype %s %s\n", caseType, fieldGoType(f.Type))
+				fmt.Fprintf(bw, "\nfunc (%s) is%s() {}\n", caseType, d.Name())
+			}
+		}
+	}
+
+	for _, proto := range mod.ExtractMethodPrototypesToplevel(nil) {
+		receiver, name, _ := strings.Cut(proto.Name, ".")
+		args := make([]string, len(proto.ArgTypes))
+		for i, a := range proto.ArgTypes {
+			args[i] = fmt.Sprintf("arg%d %s", i, fieldGoType(a))
+		}
+		fmt.Fprintf(bw, "\nfunc (t %s) %s(%s) %s {\n\tpanic(\"not implemented\")\n}\n",
+			receiver, name, strings.Join(args, ", "), fieldGoType(proto.RetType))
+	}
+
+	return bw.Flush()
+}
+
+func init() {
+	RegisterBackend(goBackend{})
+}
@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// jsonSchemaBackend emits a machine-readable dump of the whole module —
+// types, unions, and method prototypes with argument and return types — so
+// downstream tools in other languages can consume the spec without
+// reimplementing the DSL parser. It is a superset of what `methods-json`
+// prints: methods-json reports only {name, argTypes, retType}, this also
+// carries struct/union field shapes.
+type jsonSchemaBackend struct{}
+
+func (jsonSchemaBackend) Name() string { return "json-schema" }
+
+type jsonSchemaField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type jsonSchemaDecl struct {
+	Kind   string            `json:"kind"`
+	Name   string            `json:"name"`
+	Fields []jsonSchemaField `json:"fields"`
+}
+
+type jsonSchemaMethod struct {
+	Name     string   `json:"name"`
+	ArgTypes []string `json:"argTypes"`
+	RetType  string   `json:"retType"`
+}
+
+type jsonSchemaModule struct {
+	Package string             `json:"package"`
+	Types   []jsonSchemaDecl   `json:"types"`
+	Methods []jsonSchemaMethod `json:"methods"`
+}
+
+func (jsonSchemaBackend) Generate(mod Module, w io.Writer) error {
+	out := jsonSchemaModule{Package: mod.PackageName}
+
+	for _, d := range mod.Decls() {
+		decl := jsonSchemaDecl{Kind: d.Kind().String(), Name: d.Name()}
+		for _, f := range d.Fields() {
+			decl.Fields = append(decl.Fields, jsonSchemaField{Name: f.Name, Type: f.Type})
+		}
+		out.Types = append(out.Types, decl)
+	}
+
+	for _, proto := range mod.ExtractMethodPrototypesToplevel(nil) {
+		out.Methods = append(out.Methods, jsonSchemaMethod{
+			Name:     proto.Name,
+			ArgTypes: proto.ArgTypes,
+			RetType:  proto.RetType,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func init() {
+	RegisterBackend(jsonSchemaBackend{})
+}
@@ -0,0 +1,191 @@
+// Package lib implements the DSL parser, formatter, and codegen backends
+// that drive the codeGen CLI.
+package lib
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// DeclKind distinguishes the handful of top-level declaration forms the
+// DSL supports.
+type DeclKind int
+
+const (
+	DeclStruct DeclKind = iota
+	DeclUnion
+)
+
+func (k DeclKind) String() string {
+	switch k {
+	case DeclStruct:
+		return "struct"
+	case DeclUnion:
+		return "union"
+	default:
+		return "unknown"
+	}
+}
+
+// Field is a single member of a struct or case of a union.
+type Field struct {
+	Name string
+	Type string
+}
+
+// Decl is a top-level struct or union declaration.
+type Decl interface {
+	Name() string
+	Kind() DeclKind
+	Fields() []Field
+	WriteTo(w io.Writer) (int64, error)
+}
+
+type typeDecl struct {
+	kind   DeclKind
+	name   string
+	fields []Field
+}
+
+func (d *typeDecl) Name() string    { return d.name }
+func (d *typeDecl) Kind() DeclKind  { return d.kind }
+func (d *typeDecl) Fields() []Field { return d.fields }
+
+func (d *typeDecl) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	fmt.Fprintf(cw, "%s %s {\n", d.kind, d.name)
+	for _, f := range d.fields {
+		fmt.Fprintf(cw, "\t%s %s\n", f.Name, f.Type)
+	}
+	fmt.Fprintf(cw, "}\n")
+	return cw.n, cw.err
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
+
+// Method is a top-level method prototype bound to a receiver type.
+type Method struct {
+	Receiver string
+	Name     string
+	ArgTypes []string
+	RetType  string
+}
+
+// Module is a single parsed DSL file: a package name, its struct/union
+// declarations, and the method prototypes declared against them.
+type Module struct {
+	PackageName string
+
+	decls   []Decl
+	methods []Method
+}
+
+// Decls returns the module's top-level struct/union declarations, in
+// source order.
+func (m Module) Decls() []Decl {
+	return m.decls
+}
+
+// MethodPrototype describes a single method's signature, already
+// flattened to argument/return type strings for consumption by tooling
+// that doesn't want to deal with the DSL's own types.
+type MethodPrototype struct {
+	Name     string
+	ArgTypes []string
+	RetType  string
+}
+
+// ExtractMethodPrototypesToplevel returns the module's method prototypes,
+// qualified by pkgPath (e.g. the owning package's dotted path).
+func (m Module) ExtractMethodPrototypesToplevel(pkgPath []string) []MethodPrototype {
+	prefix := strings.Join(pkgPath, ".")
+	out := make([]MethodPrototype, 0, len(m.methods))
+	for _, meth := range m.methods {
+		name := meth.Receiver + "." + meth.Name
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		out = append(out, MethodPrototype{
+			Name:     name,
+			ArgTypes: meth.ArgTypes,
+			RetType:  meth.RetType,
+		})
+	}
+	return out
+}
+
+// ExtractPackageName derives a package name from a .id file path: its base
+// name with the extension stripped.
+func ExtractPackageName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Entry is a single item in a sym-style listing: either a declaration to
+// print, or a blank separator line between declarations.
+type Entry struct {
+	blank bool
+	decl  Decl
+}
+
+// EntryEmpty returns a blank separator entry.
+func EntryEmpty() Entry { return Entry{blank: true} }
+
+// EntryDecl returns an entry that prints d.
+func EntryDecl(d Decl) Entry { return Entry{decl: d} }
+
+// WriteContext carries formatting state across a sequence of
+// WriteDSLBlockEntries/WriteDSLModule calls. It currently has no fields,
+// but callers should obtain it via WriteDSLContextInit rather than a zero
+// value, so it has somewhere to grow without breaking callers.
+type WriteContext struct{}
+
+// WriteDSLContextInit returns a fresh WriteContext.
+func WriteDSLContextInit() WriteContext {
+	return WriteContext{}
+}
+
+// WriteDSLBlockEntries writes entries to w, each declaration in its
+// canonical formatted form and each blank entry as a blank line.
+func WriteDSLBlockEntries(w io.Writer, entries []Entry, _ WriteContext) {
+	for _, e := range entries {
+		if e.blank {
+			fmt.Fprintln(w)
+			continue
+		}
+		e.decl.WriteTo(w)
+	}
+}
+
+// WriteDSLModule writes mod to w in its canonical formatted form: the
+// package declaration, each struct/union declaration, and each method
+// prototype.
+func WriteDSLModule(w io.Writer, mod Module) {
+	fmt.Fprintf(w, "package %s\n", mod.PackageName)
+
+	for _, d := range mod.decls {
+		fmt.Fprintln(w)
+		d.WriteTo(w)
+	}
+
+	for _, meth := range mod.methods {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "func (%s) %s(%s) %s\n", meth.Receiver, meth.Name, strings.Join(meth.ArgTypes, ", "), meth.RetType)
+	}
+}
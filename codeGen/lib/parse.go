@@ -0,0 +1,139 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ParseError is a DSL syntax error, positioned the way compilers and
+// editors expect: filename:line:col.
+type ParseError struct {
+	Filename string
+	Line     int
+	Col      int
+	Msg      string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.Filename, e.Line, e.Col, e.Msg)
+}
+
+var (
+	packageLineRe = regexp.MustCompile(`^package\s+([A-Za-z_][A-Za-z0-9_]*)\s*$`)
+	structLineRe  = regexp.MustCompile(`^(struct|union)\s+([A-Za-z_][A-Za-z0-9_]*)\s*\{\s*$`)
+	fieldLineRe   = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\s+(\S+)\s*$`)
+	methodLineRe  = regexp.MustCompile(`^func\s*\(([A-Za-z_][A-Za-z0-9_]*)\)\s+([A-Za-z_][A-Za-z0-9_]*)\s*\(([^)]*)\)\s+(\S+)\s*$`)
+)
+
+// ParseDSLModule parses a DSL module read from src, attributing any syntax
+// error to filename:line:col.
+func ParseDSLModule(src io.Reader, filename string) (Module, error) {
+	b, err := ioutil.ReadAll(src)
+	if err != nil {
+		return Module{}, err
+	}
+	return ParseDSLModuleBytes(b, filename)
+}
+
+// ParseDSLModuleFromFile is a thin wrapper around ParseDSLModule for
+// callers that already have an open file. It panics on error, preserving
+// its historical no-error signature; new code should prefer ParseDSLModule
+// or ParseDSLModuleBytes, which surface parse errors instead.
+func ParseDSLModuleFromFile(f *os.File) Module {
+	mod, err := ParseDSLModule(f, f.Name())
+	if err != nil {
+		panic(err)
+	}
+	return mod
+}
+
+// ParseDSLModuleBytes parses a DSL module already loaded into memory,
+// attributing any syntax error to filename:line:col.
+func ParseDSLModuleBytes(src []byte, filename string) (Module, error) {
+	mod := Module{}
+	sawPackage := false
+	var cur *typeDecl
+
+	lines := strings.Split(string(src), "\n")
+	lastLine := 0
+
+	for i, raw := range lines {
+		lastLine = i + 1
+
+		trimmed := strings.TrimLeft(raw, " \t")
+		col := len(raw) - len(trimmed) + 1
+		line := strings.TrimRight(trimmed, " \t\r")
+
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		errAt := func(format string, args ...interface{}) error {
+			return &ParseError{Filename: filename, Line: i + 1, Col: col, Msg: fmt.Sprintf(format, args...)}
+		}
+
+		if cur != nil {
+			if line == "}" {
+				mod.decls = append(mod.decls, cur)
+				cur = nil
+				continue
+			}
+			m := fieldLineRe.FindStringSubmatch(line)
+			if m == nil {
+				return Module{}, errAt("malformed field declaration: %q", line)
+			}
+			cur.fields = append(cur.fields, Field{Name: m[1], Type: m[2]})
+			continue
+		}
+
+		if !sawPackage {
+			m := packageLineRe.FindStringSubmatch(line)
+			if m == nil {
+				return Module{}, errAt(`expected "package <name>", got %q`, line)
+			}
+			mod.PackageName = m[1]
+			sawPackage = true
+			continue
+		}
+
+		if m := structLineRe.FindStringSubmatch(line); m != nil {
+			kind := DeclStruct
+			if m[1] == "union" {
+				kind = DeclUnion
+			}
+			cur = &typeDecl{kind: kind, name: m[2]}
+			continue
+		}
+
+		if m := methodLineRe.FindStringSubmatch(line); m != nil {
+			var argTypes []string
+			if args := strings.TrimSpace(m[3]); args != "" {
+				for _, a := range strings.Split(args, ",") {
+					argTypes = append(argTypes, strings.TrimSpace(a))
+				}
+			}
+			mod.methods = append(mod.methods, Method{
+				Receiver: m[1],
+				Name:     m[2],
+				ArgTypes: argTypes,
+				RetType:  m[4],
+			})
+			continue
+		}
+
+		return Module{}, errAt("unrecognized declaration: %q", line)
+	}
+
+	if cur != nil {
+		return Module{}, &ParseError{Filename: filename, Line: lastLine, Col: 1, Msg: fmt.Sprintf("unterminated %s %s", cur.kind, cur.name)}
+	}
+	if !sawPackage {
+		return Module{}, &ParseError{Filename: filename, Line: lastLine, Col: 1, Msg: `missing "package" declaration`}
+	}
+
+	return mod, nil
+}
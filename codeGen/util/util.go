@@ -0,0 +1,26 @@
+// Package util holds small helpers shared across the codeGen tool and
+// library.
+package util
+
+import (
+	"fmt"
+	"os"
+)
+
+// Assert panics if cond is false. Unlike CheckErr, it does not print a
+// message for the user or exit cleanly.
+func Assert(cond bool) {
+	if !cond {
+		panic("assertion failed")
+	}
+}
+
+// CheckErr prints err to stderr and exits the process if it is non-nil. It
+// is used for errors arising from the environment (missing files, bad DSL
+// input) that should be reported to the user rather than panicking.
+func CheckErr(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// toolVersion is mixed into the cache key so that upgrading codeGen
+// invalidates previously cached gen outputs rather than serving stale ones.
+const toolVersion = "codeGen-cache-v1"
+
+// cacheManifest records the provenance of a single cached gen artifact.
+type cacheManifest struct {
+	InputHash   string `json:"inputHash"`
+	OutputHash  string `json:"outputHash"`
+	ToolVersion string `json:"toolVersion"`
+}
+
+// cacheDir returns the root of the gen cache: SPECS_CODEGEN_CACHE if set,
+// otherwise $XDG_CACHE_HOME/filecoin-specs-codegen.
+func cacheDir() string {
+	if dir := os.Getenv("SPECS_CODEGEN_CACHE"); dir != "" {
+		return dir
+	}
+	if xdg := os.Getenv("XDG_CACHE_HOME"); xdg != "" {
+		return filepath.Join(xdg, "filecoin-specs-codegen")
+	}
+	home, err := os.UserHomeDir()
+	CheckErr(err)
+	return filepath.Join(home, ".cache", "filecoin-specs-codegen")
+}
+
+// hashInput computes the cache key for a gen input: the same "hash of
+// sorted h1: lines of file(name+sha256(content))" scheme Go modules use
+// for go.sum (dirhash.Hash1), specialized to a single file plus the
+// tool's own version and output backend, so upgrades and -lang switches
+// both bust the cache instead of serving a stale or wrong-backend output.
+func hashInput(src []byte, backend string) string {
+	contentSum := sha256.Sum256(src)
+	line := fmt.Sprintf("%x  %s:%s\n", contentSum, toolVersion, backend)
+
+	h := sha256.New()
+	h.Write([]byte(line))
+	return "h1:" + base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// sha256Hex hashes b and hex-encodes the result, for verifying cached
+// artifacts haven't been tampered with or truncated on disk.
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheKey turns an inputHash (which contains ":" and "/") into a safe
+// filename component.
+func cacheKey(inputHash string) string {
+	return strings.NewReplacer(":", "_", "/", "_").Replace(inputHash)
+}
+
+// cacheLookup returns the cached gen output for inputHash, if present and
+// its manifest checks out against the current tool version and the
+// artifact's own content hash.
+func cacheLookup(inputHash string) ([]byte, bool) {
+	key := cacheKey(inputHash)
+
+	mb, err := ioutil.ReadFile(filepath.Join(cacheDir(), key+".json"))
+	if err != nil {
+		return nil, false
+	}
+	var manifest cacheManifest
+	if err := json.Unmarshal(mb, &manifest); err != nil {
+		return nil, false
+	}
+	if manifest.InputHash != inputHash || manifest.ToolVersion != toolVersion {
+		return nil, false
+	}
+
+	out, err := ioutil.ReadFile(filepath.Join(cacheDir(), key+".gen.go"))
+	if err != nil {
+		return nil, false
+	}
+	if sha256Hex(out) != manifest.OutputHash {
+		return nil, false // cache entry corrupted on disk; treat as a miss
+	}
+	return out, true
+}
+
+// cacheStore writes out to the cache under inputHash, alongside a manifest
+// recording its provenance.
+func cacheStore(inputHash string, out []byte) error {
+	dir := cacheDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	key := cacheKey(inputHash)
+	if err := ioutil.WriteFile(filepath.Join(dir, key+".gen.go"), out, 0644); err != nil {
+		return err
+	}
+
+	manifest := cacheManifest{
+		InputHash:   inputHash,
+		OutputHash:  sha256Hex(out),
+		ToolVersion: toolVersion,
+	}
+	mb, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, key+".json"), mb, 0644)
+}
+
+// cacheClean removes the entire gen cache.
+func cacheClean() error {
+	dir := cacheDir()
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	fmt.Println(dir)
+	return nil
+}
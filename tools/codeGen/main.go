@@ -9,8 +9,11 @@ import (
 	util "github.com/filecoin-project/specs/codeGen/util"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 )
 
 var Assert = util.Assert
@@ -35,10 +38,28 @@ const USAGE = `SYNOPSIS
 	%[1]s <command> src.id [out.go]
 
 COMMANDS
-	gen <idsrc> <goout>          parse <idsrc>, compile it, and output the generated Go code to <goout>
-	fmt <idsrc>                  parse <idsrc>, and overwrite the file in-place with formatted output
-	sym <idsrc> SYM1 <SYM2 ...>  parse <idsrc>, and write to stdout the contents of the given symbols
-	methods-json <idsrc>         parse <idsrc>, and write to stdout a JSON listing of its method prototypes
+	gen [-a] [-lang L] <idsrc> <out>  parse <idsrc>, compile it, and output the generated code to <out>
+	fmt [-l] [-d] <idsrc>             parse <idsrc>, and overwrite the file in-place with formatted output
+	sym <idsrc> SYM1 <SYM2 ...>       parse <idsrc>, and write to stdout the contents of the given symbols
+	methods-json <idsrc>              parse <idsrc>, and write to stdout a JSON listing of its method prototypes
+	cache clean                       remove the gen cache
+
+GEN FLAGS
+	-a        force regeneration, ignoring the cache
+	-lang L   output backend: one of "go" (default), "json-schema";
+	          "json-schema" emits a machine-readable dump of the whole module
+	          (types, unions, method prototypes) for non-Go consumers
+
+FMT FLAGS
+	-l     list files whose formatting differs from codeGen's, instead of rewriting them;
+	       exits with status 1 if any are listed
+	-d     write a diff of the formatting changes to stdout, instead of rewriting files
+	-p N   format N files concurrently (default: runtime.NumCPU())
+
+GEN CACHE
+	gen results are cached by a hash of the input and the tool's version, under
+	$XDG_CACHE_HOME/filecoin-specs-codegen (overridable via SPECS_CODEGEN_CACHE),
+	so repeated gen runs over an unchanged tree skip parsing and codegen entirely.
 
 EXAMPLES
 	# compile file.id to file.gen.go
@@ -50,6 +71,15 @@ EXAMPLES
 	# format file.id to file2.id
 	%[1]s fmt a/b/file.id a/b/file2.id
 
+	# format an entire tree in place
+	%[1]s fmt ./...
+
+	# list files that need formatting, for use in CI
+	%[1]s fmt -l ./...
+
+	# read a module from stdin and write the formatted result to stdout
+	%[1]s fmt -
+
 	# output symbol table of file.id
 	%[1]s sym a/b/file.id
 
@@ -71,11 +101,32 @@ func main() {
 	args := argsOrig[1:]
 
 	var inputFilePath, outputFilePath string
-	var inputFile, outputFile *os.File
-	var err error
+	var outputFile *os.File
+
+	var fmtList, fmtDiff bool
+	fmtWorkers := runtime.NumCPU()
+	if cmd == "fmt" {
+		fmtCmd := flag.NewFlagSet("fmt", flag.ExitOnError)
+		fmtCmd.BoolVar(&fmtList, "l", false, "list files whose formatting differs, instead of rewriting them")
+		fmtCmd.BoolVar(&fmtDiff, "d", false, "write a diff of the formatting changes to stdout, instead of rewriting files")
+		fmtCmd.IntVar(&fmtWorkers, "p", fmtWorkers, "number of files to format concurrently")
+		fmtCmd.Parse(args)
+		args = fmtCmd.Args()
+	}
+
+	var genForce bool
+	genLang := "go"
+	if cmd == "gen" {
+		genCmd := flag.NewFlagSet("gen", flag.ExitOnError)
+		genCmd.BoolVar(&genForce, "a", false, "force regeneration, ignoring the cache")
+		genCmd.StringVar(&genLang, "lang", genLang, `output backend: one of "go", "json-schema"`)
+		genCmd.Parse(args)
+		args = genCmd.Args()
+	}
 
 	// first argument
 	if cmd == "gen" || cmd == "fmt" || cmd == "sym" || cmd == "methods-json" {
+		usageAssert(len(args) >= 1, fmt.Sprintf("%v command requires an input path", cmd))
 		inputFilePath = args[0]
 	}
 
@@ -95,19 +146,47 @@ func main() {
 
 	switch cmd {
 	case "gen":
-		goMod := codeGen.GenGoModFromFilePath(inputFilePath)
+		backend, ok := codeGen.GetBackend(genLang)
+		usageAssert(ok, fmt.Sprintf("unknown gen backend: %q", genLang))
+
+		src, err := ioutil.ReadFile(inputFilePath)
+		CheckErr(err)
+		inputHash := hashInput(src, backend.Name())
+
+		var outb []byte
+		if !genForce {
+			outb, _ = cacheLookup(inputHash)
+		}
+		if outb == nil {
+			var genErr error
+			outb, genErr = genBytes(inputFilePath, src, backend)
+			CheckErr(genErr)
+			CheckErr(cacheStore(inputHash, outb))
+		}
+
 		outputFile, err = os.Create(outputFilePath)
 		CheckErr(err)
-		codeGen.WriteGoMod(goMod, outputFile)
+		_, err = outputFile.Write(outb)
+		CheckErr(err)
+
+	case "cache":
+		usageAssert(len(args) == 1 && args[0] == "clean", "cache command only supports the \"clean\" subcommand")
+		CheckErr(cacheClean())
 
 	case "fmt":
-		fmtFiles(extractIdFiles(inputFilePath))
+		if inputFilePath == "-" {
+			usageAssert(!fmtList && !fmtDiff, "-l and -d are not supported when reading from stdin")
+			CheckErr(fmtStdin())
+		} else {
+			fmtFiles(extractIdFiles(inputFilePath), fmtList, fmtDiff, fmtWorkers)
+		}
 
 	case "sym":
-		inputFile, err = os.Open(inputFilePath)
-		CheckErr(err)
 		Assert(len(args) >= 2)
-		mod := codeGen.ParseDSLModuleFromFile(inputFile)
+		src, err := ioutil.ReadFile(inputFilePath)
+		CheckErr(err)
+		mod, err := codeGen.ParseDSLModuleBytes(src, inputFilePath)
+		CheckErr(err)
 		decls := mod.Decls()
 		declsMap := map[string]codeGen.Decl{}
 		for _, decl := range decls {
@@ -129,9 +208,10 @@ func main() {
 	case "methods-json":
 		entriesJson := []map[string]interface{}{}
 		for _, idPath := range extractIdFiles(inputFilePath) {
-			idFile, err := os.Open(idPath)
+			src, err := ioutil.ReadFile(idPath)
+			CheckErr(err)
+			mod, err := codeGen.ParseDSLModuleBytes(src, idPath)
 			CheckErr(err)
-			mod := codeGen.ParseDSLModuleFromFile(idFile)
 			packageName := codeGen.ExtractPackageName(idPath)
 			for _, entry := range mod.ExtractMethodPrototypesToplevel([]string{packageName}) {
 				entryJson := map[string]interface{}{}
@@ -150,6 +230,22 @@ func main() {
 	}
 }
 
+// genBytes parses src (the contents of inputFilePath) and runs it through
+// backend, returning the generated output as bytes, suitable for writing
+// straight to disk or into the cache.
+func genBytes(inputFilePath string, src []byte, backend codeGen.Backend) ([]byte, error) {
+	mod, err := codeGen.ParseDSLModuleBytes(src, inputFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	outb := bytes.NewBuffer(nil)
+	if err := backend.Generate(mod, outb); err != nil {
+		return nil, err
+	}
+	return outb.Bytes(), nil
+}
+
 func extractIdFiles(inputPath string) []string {
 	if strings.HasSuffix(inputPath, "/...") {
 		return findFiles(filepath.Dir(inputPath), func(path string) bool {
@@ -182,41 +278,151 @@ func findFiles(inputPath string, filter func(path string) bool) []string {
 	return files
 }
 
-func fmtFile(inpath, outpath string) error {
-	inf, err := os.Open(inpath)
+// fmtOutMu serializes only stdout output (rewritten paths, -l listings, -d
+// diffs) across the fmtFiles worker pool so concurrent files don't
+// interleave output; the per-file reads and writes themselves run
+// unsynchronized so they can overlap.
+var fmtOutMu sync.Mutex
+
+// fmtFile formats inpath and, depending on list/diff, either lists it,
+// prints a diff of the change, or rewrites outpath in place, preserving
+// inpath's file mode. It reports whether the formatted output differed
+// from the source. The source is read exactly once.
+func fmtFile(inpath, outpath string, list, diff bool) (bool, error) {
+	inb, err := ioutil.ReadFile(inpath)
 	if err != nil {
-		return err
+		return false, err
 	}
-	defer inf.Close()
 
-	mod := codeGen.ParseDSLModuleFromFile(inf)
+	mod, err := codeGen.ParseDSLModuleBytes(inb, inpath)
+	if err != nil {
+		return false, err
+	}
 	outb := bytes.NewBuffer(nil)
 	codeGen.WriteDSLModule(outb, mod)
 
-	// only write if there are differences.
-	// TODO: make this faster. interleaved io + cpu. goroutines maybe
-	// TODO: read src once. we read src twice because ParseDSLModuleFromFile
-	// 			 only takes files.
-	inb, err := ioutil.ReadFile(inpath)
-	if err != nil {
-		return err
+	if bytes.Equal(outb.Bytes(), inb) {
+		return false, nil
 	}
 
-	if !bytes.Equal(outb.Bytes(), inb) {
-		err := ioutil.WriteFile(outpath, outb.Bytes(), 0777)
+	if !list && !diff {
+		fi, err := os.Stat(inpath) // os.Stat follows symlinks, so this resolves to the target's mode
 		if err != nil {
-			return err
+			return true, err
+		}
+		if err := ioutil.WriteFile(outpath, outb.Bytes(), fi.Mode()&os.ModePerm); err != nil {
+			return true, err
+		}
+	}
+
+	fmtOutMu.Lock()
+	defer fmtOutMu.Unlock()
+
+	switch {
+	case list:
+		fmt.Println(inpath)
+	case diff:
+		if err := printDiff(inpath, inb, outb.Bytes()); err != nil {
+			return true, err
 		}
+	default:
 		fmt.Println(outpath) // go fmt ./... prints which files it wrote
-	} else {
-		// fmt.Println(inpath, "ignored")
 	}
+	return true, nil
+}
+
+type fmtResult struct {
+	changed bool
+	err     error
+}
+
+// fmtFiles formats files concurrently across a pool of workers goroutines,
+// each pulling paths off a shared channel. In list mode it exits with
+// status 1 if any file needed reformatting.
+func fmtFiles(files []string, list, diff bool, workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	paths := make(chan string)
+	results := make(chan fmtResult)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for f := range paths {
+				changed, err := fmtFile(f, f, list, diff)
+				results <- fmtResult{changed, err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	go func() {
+		for _, f := range files {
+			paths <- f
+		}
+		close(paths)
+	}()
+
+	dirty := false
+	for r := range results {
+		CheckErr(r.err)
+		dirty = dirty || r.changed
+	}
+
+	if list && dirty {
+		os.Exit(1)
+	}
+}
+
+// fmtStdin reads a DSL module from stdin, formats it, and writes the result
+// to stdout, so editor-on-save integrations can pipe buffers through the tool.
+func fmtStdin() error {
+	mod, err := codeGen.ParseDSLModule(os.Stdin, "<stdin>")
+	if err != nil {
+		return err
+	}
+	codeGen.WriteDSLModule(os.Stdout, mod)
 	return nil
 }
 
-func fmtFiles(files []string) {
-	for _, f := range files {
-		err := fmtFile(f, f)
-		CheckErr(err)
+// printDiff writes a unified diff between inb and outb to stdout, labeled
+// with path. It shells out to the system "diff" tool, the same approach
+// gofmt itself used before Go grew a builtin diff implementation.
+func printDiff(path string, inb, outb []byte) error {
+	origFile, err := ioutil.TempFile("", "codeGen-orig-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(origFile.Name())
+	defer origFile.Close()
+	if _, err := origFile.Write(inb); err != nil {
+		return err
 	}
+
+	newFile, err := ioutil.TempFile("", "codeGen-fmt-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(newFile.Name())
+	defer newFile.Close()
+	if _, err := newFile.Write(outb); err != nil {
+		return err
+	}
+
+	out, err := exec.Command("diff", "-u", origFile.Name(), newFile.Name()).CombinedOutput()
+	// diff exits 1 when the inputs differ, which is the expected case here.
+	if len(out) == 0 {
+		return err
+	}
+	out = bytes.Replace(out, []byte(origFile.Name()), []byte(path+".orig"), 1)
+	out = bytes.Replace(out, []byte(newFile.Name()), []byte(path), 1)
+	os.Stdout.Write(out)
+	return nil
 }